@@ -1,12 +1,17 @@
 package caddy_libp2p_listener
 
 import (
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"sync"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
 	"go.uber.org/zap"
 )
 
@@ -17,15 +22,56 @@ func init() {
 
 // App is the libp2p Caddy app used to configure libp2p nodes.
 type App struct {
-	// PrivateKey is the location of the private key as a PEM file
-	PrivateKey string `json:"private_key,omitempty" caddy:"namespace=libp2p.key"`
+	// PrivateKeyRaw loads the libp2p identity's private key from a
+	// libp2p.key module, e.g. "file" (a PEM file on disk), "inline" (a
+	// base64 seed in the config), "env" (a base64 seed in an environment
+	// variable), "generated" (an ephemeral key generated on first use and
+	// persisted to Caddy storage), "storage" (a key pre-provisioned in
+	// Caddy storage, shared across a cluster), or "external" (an
+	// HSM/gRPC-backed signer). If unset, libp2p.New generates a fresh
+	// ephemeral identity on every start.
+	PrivateKeyRaw json.RawMessage `json:"private_key,omitempty" caddy:"namespace=libp2p.key inline_key=provider"`
 
 	// AdvertiseAmino indicates if the peer's addresses should be adverised in the Amino DHT
 	// This is particularly useful for WebTransport and WebRTC which have their certificate hashes rotate
 	// even while their peerID remains the same
 	AdvertiseAmino bool `json:"advertise_amino,omitempty" caddy:"namespace=libp2p.advertise_amino"`
 
-	logger *zap.Logger
+	// ListenerRaw configures the underlying libp2p host through the
+	// libp2p.listener module family: transports, NAT/relay behavior,
+	// announced addresses, address filters, connection manager watermarks,
+	// and resource manager scaling. If unset, falls back to
+	// DefaultTransports plus WebRTC, matching the behavior of earlier
+	// versions of this module.
+	ListenerRaw json.RawMessage `json:"listener,omitempty" caddy:"namespace=libp2p inline_key=module"`
+
+	// AutoCert configures a p2p-forge-style automated certificate subsystem
+	// that keeps WebTransport/WebRTC certhashes fresh without manual
+	// intervention. If unset, certificates are the ones go-libp2p's
+	// transports generate and rotate on their own.
+	AutoCert *AutoCertConfig `json:"auto_cert,omitempty"`
+
+	// Gater configures connection gating: multiaddr/CIDR and PeerID
+	// allow/deny lists, and a per-IP inbound connection cap. If unset, the
+	// host accepts any connection that clears ListenerRaw's AddressFilters.
+	Gater *GaterConfig `json:"gater,omitempty"`
+
+	listener    *ListenerConfig
+	keyProvider KeyProvider
+	gater       *libp2pGater
+	logger      *zap.Logger
+	ctx         caddy.Context
+
+	host     host.Host
+	hostOnce sync.Once
+	hostErr  error
+
+	ps         *pubsub.PubSub
+	psOnce     sync.Once
+	psErr      error
+	psTopic    string
+	psMaxSize  int64
+	psHasScore bool
 }
 
 func (App) CaddyModule() caddy.ModuleInfo {
@@ -37,14 +83,77 @@ func (App) CaddyModule() caddy.ModuleInfo {
 
 func (t *App) Provision(ctx caddy.Context) error {
 	t.logger = ctx.Logger(t)
+	t.ctx = ctx
+
+	if t.PrivateKeyRaw != nil {
+		mod, err := ctx.LoadModule(t, "PrivateKeyRaw")
+		if err != nil {
+			return fmt.Errorf("loading libp2p key provider: %w", err)
+		}
+		kp, ok := mod.(KeyProvider)
+		if !ok {
+			return fmt.Errorf("key provider module is not a KeyProvider: %T", mod)
+		}
+		t.keyProvider = kp
+	}
+
+	if t.ListenerRaw != nil {
+		mod, err := ctx.LoadModule(t, "ListenerRaw")
+		if err != nil {
+			return fmt.Errorf("loading libp2p listener module: %w", err)
+		}
+		lc, ok := mod.(*ListenerConfig)
+		if !ok {
+			return fmt.Errorf("listener module is not a *ListenerConfig: %T", mod)
+		}
+		t.listener = lc
+	}
+
+	if t.AutoCert != nil {
+		if err := t.AutoCert.provision(ctx, t); err != nil {
+			return fmt.Errorf("provisioning auto_cert: %w", err)
+		}
+	}
+
+	if t.Gater != nil {
+		gt, err := t.Gater.build()
+		if err != nil {
+			return fmt.Errorf("provisioning gater: %w", err)
+		}
+		t.gater = gt
+	}
+
 	return nil
 }
 
+// ConnectionGater returns the connmgr.ConnectionGater built from Gater, or
+// nil if unconfigured.
+func (t *App) ConnectionGater() *libp2pGater {
+	return t.gater
+}
+
+// Listener returns the structured listener configuration loaded from
+// ListenerRaw, or nil if none was configured.
+func (t *App) Listener() *ListenerConfig {
+	return t.listener
+}
+
 func (t *App) Start() error {
+	if cm := t.CertManager(); cm != nil {
+		if err := cm.Start(); err != nil {
+			return fmt.Errorf("starting auto_cert manager: %w", err)
+		}
+	}
 	return nil
 }
 
 func (t *App) Stop() error {
+	if cm := t.CertManager(); cm != nil {
+		cm.Stop()
+	}
+	if t.host != nil {
+		return t.host.Close()
+	}
 	return nil
 }
 
@@ -73,7 +182,7 @@ func parseAppConfig(d *caddyfile.Dispenser, _ any) (any, error) {
 			if !d.NextArg() {
 				return nil, d.ArgErr()
 			}
-			app.PrivateKey = d.Val()
+			app.PrivateKeyRaw = caddyconfig.JSONModuleObject(FileKeyProvider{Path: d.Val()}, "provider", "file", nil)
 		default:
 			return nil, d.Errf("unrecognized directive: %s", d.Val())
 		}
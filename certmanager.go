@@ -0,0 +1,79 @@
+package caddy_libp2p_listener
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	p2pforge "github.com/ipshipyard/p2p-forge/client"
+)
+
+// AutoCertConfig configures a p2p-forge-style automated certificate
+// subsystem for this host. WebTransport and WebRTC certhashes rotate even
+// while the PeerID stays stable (see AdvertiseAmino), which normally forces
+// operators to manually reissue and republish certificates. When AutoCert is
+// set, the host instead obtains an ACME-issued certificate for a
+// "<peer-id>.<Domain>"-style hostname derived from its PeerID, renews it
+// automatically, and feeds the updated certhash into the listener's
+// AddrsFactory so advertised multiaddrs always reflect the live cert.
+type AutoCertConfig struct {
+	// Domain is the base domain certificates are issued under, e.g.
+	// "libp2p.direct". The host's PeerID (base36-encoded) is used as the
+	// subdomain.
+	Domain string `json:"domain,omitempty"`
+
+	// CAEndpoint is the ACME directory URL used to request certificates.
+	// Defaults to Let's Encrypt's production directory.
+	CAEndpoint string `json:"ca_endpoint,omitempty"`
+
+	// RegistrationEndpoint is the p2p-forge DNS registration service used to
+	// publish the PeerID -> Domain delegation needed for DNS-01 challenges.
+	RegistrationEndpoint string `json:"registration_endpoint,omitempty"`
+
+	// RenewalWindow is how long before expiry a certificate is renewed, as a
+	// Go duration string. Defaults to p2p-forge's own default.
+	RenewalWindow string `json:"renewal_window,omitempty"`
+
+	mgr *p2pforge.P2PForgeCertMgr
+}
+
+// provision builds the underlying p2p-forge certificate manager. It is
+// called from App.Provision, after the App's key provider is loaded; it does
+// not read the private key, and the host (and therefore the PeerID) is only
+// built later, by App.Host.
+func (a *AutoCertConfig) provision(ctx caddy.Context, app *App) error {
+	opts := []p2pforge.P2PForgeCertMgrConfig{}
+	if a.Domain != "" {
+		opts = append(opts, p2pforge.WithForgeDomain(a.Domain))
+	}
+	if a.CAEndpoint != "" {
+		opts = append(opts, p2pforge.WithCAEndpoint(a.CAEndpoint))
+	}
+	if a.RegistrationEndpoint != "" {
+		opts = append(opts, p2pforge.WithForgeRegistrationEndpoint(a.RegistrationEndpoint))
+	}
+	if a.RenewalWindow != "" {
+		window, err := time.ParseDuration(a.RenewalWindow)
+		if err != nil {
+			return fmt.Errorf("parsing auto_cert renewal_window: %w", err)
+		}
+		opts = append(opts, p2pforge.WithCertificateRenewalWindow(window))
+	}
+	opts = append(opts, p2pforge.WithLogger(app.logger.Sugar()))
+
+	mgr, err := p2pforge.NewP2PForgeCertMgr(opts...)
+	if err != nil {
+		return fmt.Errorf("building p2p-forge certificate manager: %w", err)
+	}
+	a.mgr = mgr
+	return nil
+}
+
+// CertManager returns the AutoCert subsystem's certificate manager, or nil
+// if AutoCert isn't configured.
+func (t *App) CertManager() *p2pforge.P2PForgeCertMgr {
+	if t.AutoCert == nil {
+		return nil
+	}
+	return t.AutoCert.mgr
+}
@@ -0,0 +1,279 @@
+package caddy_libp2p_listener
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/net/gostream"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	mafilt "github.com/whyrusleeping/multiaddr-filter"
+)
+
+// GaterConfig configures a connmgr.ConnectionGater for the App's shared
+// host: multiaddr/CIDR deny+allow lists (using the same
+// whyrusleeping/multiaddr-filter mask syntax as kubo's AddrFilters),
+// per-PeerID allow/deny lists, and a cap on inbound connections per source
+// IP, since TCP source IPs are meaningless once relays/WebRTC are in play.
+type GaterConfig struct {
+	// AllowAddrs are multiaddr/CIDR masks that are always accepted, taking
+	// priority over DenyAddrs.
+	AllowAddrs []string `json:"allow_addrs,omitempty"`
+
+	// DenyAddrs are multiaddr/CIDR masks that are rejected unless also
+	// matched by AllowAddrs.
+	DenyAddrs []string `json:"deny_addrs,omitempty"`
+
+	// AllowPeers are PeerIDs that are always accepted, taking priority over
+	// DenyPeers.
+	AllowPeers []string `json:"allow_peers,omitempty"`
+
+	// DenyPeers are PeerIDs that are rejected unless also matched by
+	// AllowPeers.
+	DenyPeers []string `json:"deny_peers,omitempty"`
+
+	// MaxInboundConnsPerIP caps the number of live inbound connections
+	// accepted from a single source IP. Zero disables the cap.
+	MaxInboundConnsPerIP int `json:"max_inbound_conns_per_ip,omitempty"`
+
+	allowAddrs *multiaddr.Filters
+	denyAddrs  *multiaddr.Filters
+	allowPeers map[peer.ID]struct{}
+	denyPeers  map[peer.ID]struct{}
+
+	mu        sync.Mutex
+	connsByIP map[string]int
+}
+
+// build parses GaterConfig's string fields into the gater's matching
+// structures.
+func (g *GaterConfig) build() (*libp2pGater, error) {
+	gt := &libp2pGater{cfg: g}
+
+	if len(g.AllowAddrs) > 0 {
+		f, err := parseAddrMasks(g.AllowAddrs)
+		if err != nil {
+			return nil, fmt.Errorf("parsing allow_addrs: %w", err)
+		}
+		g.allowAddrs = f
+	}
+	if len(g.DenyAddrs) > 0 {
+		f, err := parseAddrMasks(g.DenyAddrs)
+		if err != nil {
+			return nil, fmt.Errorf("parsing deny_addrs: %w", err)
+		}
+		g.denyAddrs = f
+	}
+
+	g.allowPeers = make(map[peer.ID]struct{}, len(g.AllowPeers))
+	for _, s := range g.AllowPeers {
+		id, err := peer.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing allow_peers entry %q: %w", s, err)
+		}
+		g.allowPeers[id] = struct{}{}
+	}
+	g.denyPeers = make(map[peer.ID]struct{}, len(g.DenyPeers))
+	for _, s := range g.DenyPeers {
+		id, err := peer.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing deny_peers entry %q: %w", s, err)
+		}
+		g.denyPeers[id] = struct{}{}
+	}
+
+	g.connsByIP = make(map[string]int)
+
+	return gt, nil
+}
+
+func parseAddrMasks(masks []string) (*multiaddr.Filters, error) {
+	filters := multiaddr.NewFilters()
+	for _, m := range masks {
+		ipnet, err := mafilt.NewMask(m)
+		if err != nil {
+			return nil, fmt.Errorf("parsing mask %q: %w", m, err)
+		}
+		filters.AddFilter(*ipnet, multiaddr.ActionDeny)
+	}
+	return filters, nil
+}
+
+// libp2pGater implements connmgr.ConnectionGater against a GaterConfig.
+type libp2pGater struct {
+	cfg *GaterConfig
+}
+
+func (gt *libp2pGater) peerAllowed(p peer.ID) bool {
+	if _, ok := gt.cfg.allowPeers[p]; ok {
+		return true
+	}
+	_, denied := gt.cfg.denyPeers[p]
+	return !denied
+}
+
+func (gt *libp2pGater) addrAllowed(a multiaddr.Multiaddr) bool {
+	if gt.cfg.allowAddrs != nil && gt.cfg.allowAddrs.AddrBlocked(a) {
+		return true
+	}
+	if gt.cfg.denyAddrs != nil && gt.cfg.denyAddrs.AddrBlocked(a) {
+		return false
+	}
+	return true
+}
+
+func (gt *libp2pGater) InterceptPeerDial(p peer.ID) bool {
+	return gt.peerAllowed(p)
+}
+
+func (gt *libp2pGater) InterceptAddrDial(p peer.ID, a multiaddr.Multiaddr) bool {
+	return gt.peerAllowed(p) && gt.addrAllowed(a)
+}
+
+func (gt *libp2pGater) InterceptAccept(conn network.ConnMultiaddrs) bool {
+	if !gt.addrAllowed(conn.RemoteMultiaddr()) {
+		return false
+	}
+
+	if gt.cfg.MaxInboundConnsPerIP == 0 {
+		return true
+	}
+	ip, err := manet.ToIP(conn.RemoteMultiaddr())
+	if err != nil {
+		return true
+	}
+	key := ip.String()
+
+	gt.cfg.mu.Lock()
+	defer gt.cfg.mu.Unlock()
+	if gt.cfg.connsByIP[key] >= gt.cfg.MaxInboundConnsPerIP {
+		return false
+	}
+	gt.cfg.connsByIP[key]++
+	return true
+}
+
+// Disconnected implements part of network.Notifiee (via notifyBundle,
+// registered by newHost against the shared host's Network) so
+// MaxInboundConnsPerIP tracks live connections instead of ratcheting up
+// forever. It undoes the increment InterceptAccept made for conn, if any.
+func (gt *libp2pGater) Disconnected(_ network.Network, conn network.Conn) {
+	if gt.cfg.MaxInboundConnsPerIP == 0 || conn.Stat().Direction != network.DirInbound {
+		return
+	}
+	ip, err := manet.ToIP(conn.RemoteMultiaddr())
+	if err != nil {
+		return
+	}
+	key := ip.String()
+
+	gt.cfg.mu.Lock()
+	defer gt.cfg.mu.Unlock()
+	if gt.cfg.connsByIP[key] > 0 {
+		gt.cfg.connsByIP[key]--
+		if gt.cfg.connsByIP[key] == 0 {
+			delete(gt.cfg.connsByIP, key)
+		}
+	}
+}
+
+// notifyBundle returns a network.Notifiee that decrements connsByIP as
+// connections close. Registered against the host's Network in newHost so
+// MaxInboundConnsPerIP reflects live connections rather than a cumulative,
+// never-reset count.
+func (gt *libp2pGater) notifyBundle() network.Notifiee {
+	return &network.NotifyBundle{DisconnectedF: gt.Disconnected}
+}
+
+func (gt *libp2pGater) InterceptSecured(_ network.Direction, p peer.ID, conn network.ConnMultiaddrs) bool {
+	return gt.peerAllowed(p) && gt.addrAllowed(conn.RemoteMultiaddr())
+}
+
+func (gt *libp2pGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+var _ connmgr.ConnectionGater = (*libp2pGater)(nil)
+
+// Libp2pPeerInfo is a middleware that exposes the remote peer's PeerID and
+// observed multiaddr as the {http.request.libp2p.peer} and
+// {http.request.libp2p.maddr} placeholders, so existing Caddy directives
+// (rate_limit, log filters, @matcher blocks) can act on libp2p-level
+// identity instead of TCP source IPs, which are meaningless behind relays
+// or WebRTC.
+type Libp2pPeerInfo struct{}
+
+// CaddyModule implements caddy.Module.
+func (Libp2pPeerInfo) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.libp2p_peer_info",
+		New: func() caddy.Module { return new(Libp2pPeerInfo) },
+	}
+}
+
+// ServeHTTP implements caddyhttp.MiddlewareHandler.
+func (Libp2pPeerInfo) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	repl := r.Context().Value(caddyhttp.ReplacerCtxKey).(*caddy.Replacer)
+	if conn, ok := r.Context().Value(caddyhttp.ConnCtxKey).(net.Conn); ok {
+		if gsConn, ok := conn.(*gostream.Conn); ok {
+			stream := gsConn.Stream()
+			repl.Set("http.request.libp2p.peer", stream.Conn().RemotePeer().String())
+			repl.Set("http.request.libp2p.maddr", stream.Conn().RemoteMultiaddr().String())
+		}
+	}
+	return next.ServeHTTP(w, r)
+}
+
+var (
+	_ caddy.Module                = (*Libp2pPeerInfo)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Libp2pPeerInfo)(nil)
+)
+
+// Libp2pPeerMatcher is a Caddy request matcher that matches requests coming
+// from one of a configured set of libp2p PeerIDs, using the PeerID exposed
+// by Libp2pPeerInfo.
+type Libp2pPeerMatcher struct {
+	// Peers are the PeerIDs this matcher accepts.
+	Peers []string `json:"peers,omitempty"`
+}
+
+// CaddyModule implements caddy.Module.
+func (Libp2pPeerMatcher) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.libp2p_peer",
+		New: func() caddy.Module { return new(Libp2pPeerMatcher) },
+	}
+}
+
+// Match implements caddyhttp.RequestMatcher.
+func (m Libp2pPeerMatcher) Match(r *http.Request) bool {
+	repl := r.Context().Value(caddyhttp.ReplacerCtxKey).(*caddy.Replacer)
+	peerID, ok := repl.GetString("http.request.libp2p.peer")
+	if !ok {
+		return false
+	}
+	for _, p := range m.Peers {
+		if p == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	_ caddy.Module             = (*Libp2pPeerMatcher)(nil)
+	_ caddyhttp.RequestMatcher = (*Libp2pPeerMatcher)(nil)
+)
+
+func init() {
+	caddy.RegisterModule(new(Libp2pPeerInfo))
+	caddy.RegisterModule(new(Libp2pPeerMatcher))
+}
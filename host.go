@@ -0,0 +1,66 @@
+package caddy_libp2p_listener
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// Host lazily builds and returns the libp2p host shared by every listener,
+// reverse_proxy transport, and pubsub handler configured against this App,
+// so a single libp2p node serves all of them rather than one host per
+// listen directive.
+func (t *App) Host(ctx context.Context) (host.Host, error) {
+	t.hostOnce.Do(func() {
+		t.host, t.hostErr = t.newHost(ctx)
+	})
+	return t.host, t.hostErr
+}
+
+func (t *App) newHost(ctx context.Context) (host.Host, error) {
+	sk, err := t.loadPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := buildListenerOptions(t, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building libp2p host options: %w", err)
+	}
+	if sk != nil {
+		opts = append(opts, libp2p.Identity(sk))
+	}
+	if t.AdvertiseAmino {
+		opts = append(opts, libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
+			return dht.New(ctx, h, dht.Mode(dht.ModeClient))
+		}))
+	}
+
+	h, err := libp2p.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if t.gater != nil {
+		h.Network().Notify(t.gater.notifyBundle())
+	}
+	return h, nil
+}
+
+// loadPrivateKey asks the configured KeyProvider for the libp2p identity's
+// private key, or returns a nil key (letting libp2p.New generate an
+// ephemeral identity) if no provider is configured.
+func (t *App) loadPrivateKey() (crypto.PrivKey, error) {
+	if t.keyProvider == nil {
+		return nil, nil
+	}
+	sk, err := t.keyProvider.PrivateKey(t.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading private key: %w", err)
+	}
+	return sk, nil
+}
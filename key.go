@@ -0,0 +1,33 @@
+package caddy_libp2p_listener
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// KeyProvider supplies the libp2p host's identity private key. Concrete
+// implementations are loaded from App.PrivateKeyRaw via the libp2p.key
+// module namespace, so the identity can come from a PEM file, an inline
+// seed, an environment variable, Caddy's storage backend, or an external
+// HSM/gRPC-backed signer, without App itself knowing which.
+type KeyProvider interface {
+	PrivateKey(ctx caddy.Context) (crypto.PrivKey, error)
+}
+
+// unmarshalSeed decodes a standard-base64-encoded libp2p private key, as
+// produced by crypto.MarshalPrivateKey, used by the inline and env key
+// providers.
+func unmarshalSeed(seed string) (crypto.PrivKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 key: %w", err)
+	}
+	sk, err := crypto.UnmarshalPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling private key: %w", err)
+	}
+	return sk, nil
+}
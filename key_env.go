@@ -0,0 +1,47 @@
+package caddy_libp2p_listener
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func init() {
+	caddy.RegisterModule(new(EnvKeyProvider))
+}
+
+// EnvKeyProvider loads the libp2p identity from a base64-encoded seed held
+// in an environment variable, keeping the key out of both the Caddy config
+// and disk.
+type EnvKeyProvider struct {
+	// Var is the name of the environment variable holding the
+	// standard-base64-encoded, libp2p-marshaled private key.
+	Var string `json:"var,omitempty"`
+}
+
+// CaddyModule implements caddy.Module.
+func (EnvKeyProvider) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "libp2p.key.env",
+		New: func() caddy.Module { return new(EnvKeyProvider) },
+	}
+}
+
+// PrivateKey implements KeyProvider.
+func (e *EnvKeyProvider) PrivateKey(caddy.Context) (crypto.PrivKey, error) {
+	if e.Var == "" {
+		return nil, fmt.Errorf("libp2p.key.env: var is required")
+	}
+	seed, ok := os.LookupEnv(e.Var)
+	if !ok {
+		return nil, fmt.Errorf("libp2p.key.env: environment variable %q is not set", e.Var)
+	}
+	return unmarshalSeed(seed)
+}
+
+var (
+	_ caddy.Module = (*EnvKeyProvider)(nil)
+	_ KeyProvider  = (*EnvKeyProvider)(nil)
+)
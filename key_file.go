@@ -0,0 +1,74 @@
+package caddy_libp2p_listener
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func init() {
+	caddy.RegisterModule(new(FileKeyProvider))
+}
+
+// FileKeyProvider loads the libp2p identity from a PKCS#8 PEM file on disk.
+// This is the original key-loading behavior of this module, now exposed as
+// one of several libp2p.key providers.
+type FileKeyProvider struct {
+	// Path is the location of the private key as a PEM file.
+	Path string `json:"path,omitempty"`
+}
+
+// CaddyModule implements caddy.Module.
+func (FileKeyProvider) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "libp2p.key.file",
+		New: func() caddy.Module { return new(FileKeyProvider) },
+	}
+}
+
+// PrivateKey implements KeyProvider.
+func (f *FileKeyProvider) PrivateKey(caddy.Context) (crypto.PrivKey, error) {
+	if f.Path == "" {
+		return nil, fmt.Errorf("libp2p.key.file: path is required")
+	}
+
+	privFile, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	pemBlock, rest := pem.Decode(privFile)
+	if pemBlock == nil {
+		return nil, fmt.Errorf("PEM block not found in input data:\n%s", rest)
+	}
+	if pemBlock.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("expected PRIVATE KEY type in PEM block but got: %s", pemBlock.Type)
+	}
+
+	stdKey, err := x509.ParsePKCS8PrivateKey(pemBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing PKCS8 format: %w", err)
+	}
+
+	// In case ed25519.PrivateKey is returned we need the pointer for
+	// conversion to libp2p keys
+	if ed25519KeyPointer, ok := stdKey.(ed25519.PrivateKey); ok {
+		stdKey = &ed25519KeyPointer
+	}
+
+	sk, _, err := crypto.KeyPairFromStdKey(stdKey)
+	if err != nil {
+		return nil, fmt.Errorf("converting std Go key to libp2p key: %w", err)
+	}
+	return sk, nil
+}
+
+var (
+	_ caddy.Module = (*FileKeyProvider)(nil)
+	_ KeyProvider  = (*FileKeyProvider)(nil)
+)
@@ -0,0 +1,66 @@
+package caddy_libp2p_listener
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func init() {
+	caddy.RegisterModule(new(GeneratedKeyProvider))
+}
+
+// GeneratedKeyProvider generates an Ed25519 libp2p identity the first time
+// it runs and persists it to Caddy's configured storage backend, reusing
+// the same key on every subsequent start. Unlike StorageKeyProvider, it
+// never requires a key to already exist.
+type GeneratedKeyProvider struct {
+	// StorageKey is the Caddy storage key the generated identity is stored
+	// under. Defaults to "libp2p/generated_identity.key".
+	StorageKey string `json:"storage_key,omitempty"`
+}
+
+// CaddyModule implements caddy.Module.
+func (GeneratedKeyProvider) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "libp2p.key.generated",
+		New: func() caddy.Module { return new(GeneratedKeyProvider) },
+	}
+}
+
+// PrivateKey implements KeyProvider.
+func (g *GeneratedKeyProvider) PrivateKey(ctx caddy.Context) (crypto.PrivKey, error) {
+	key := g.StorageKey
+	if key == "" {
+		key = "libp2p/generated_identity.key"
+	}
+	store := ctx.Storage()
+
+	if exists := store.Exists(ctx, key); exists {
+		raw, err := store.Load(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("loading generated identity: %w", err)
+		}
+		return crypto.UnmarshalPrivateKey(raw)
+	}
+
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating identity: %w", err)
+	}
+	raw, err := crypto.MarshalPrivateKey(sk)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling generated identity: %w", err)
+	}
+	if err := store.Store(ctx, key, raw); err != nil {
+		return nil, fmt.Errorf("persisting generated identity: %w", err)
+	}
+	return sk, nil
+}
+
+var (
+	_ caddy.Module = (*GeneratedKeyProvider)(nil)
+	_ KeyProvider  = (*GeneratedKeyProvider)(nil)
+)
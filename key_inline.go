@@ -0,0 +1,42 @@
+package caddy_libp2p_listener
+
+import (
+	"fmt"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func init() {
+	caddy.RegisterModule(new(InlineKeyProvider))
+}
+
+// InlineKeyProvider loads the libp2p identity from a base64-encoded seed
+// embedded directly in the Caddy config, as produced by
+// crypto.MarshalPrivateKey. Useful for tests and environments where the
+// config itself is already handled as a secret.
+type InlineKeyProvider struct {
+	// Seed is the standard-base64-encoded, libp2p-marshaled private key.
+	Seed string `json:"seed,omitempty"`
+}
+
+// CaddyModule implements caddy.Module.
+func (InlineKeyProvider) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "libp2p.key.inline",
+		New: func() caddy.Module { return new(InlineKeyProvider) },
+	}
+}
+
+// PrivateKey implements KeyProvider.
+func (i *InlineKeyProvider) PrivateKey(caddy.Context) (crypto.PrivKey, error) {
+	if i.Seed == "" {
+		return nil, fmt.Errorf("libp2p.key.inline: seed is required")
+	}
+	return unmarshalSeed(i.Seed)
+}
+
+var (
+	_ caddy.Module = (*InlineKeyProvider)(nil)
+	_ KeyProvider  = (*InlineKeyProvider)(nil)
+)
@@ -0,0 +1,58 @@
+package caddy_libp2p_listener
+
+import (
+	"fmt"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func init() {
+	caddy.RegisterModule(new(StorageKeyProvider))
+}
+
+// StorageKeyProvider loads the libp2p identity from Caddy's configured
+// storage backend (e.g. a shared filesystem, Consul, or etcd module), which
+// lets every node in a clustered Caddy deployment load the same identity
+// instead of each generating its own. Unlike GeneratedKeyProvider, it never
+// generates a key: the key must already have been provisioned under Key,
+// for example by an operator running this module once with
+// libp2p.key.generated and copying the resulting storage key, or by a
+// separate bootstrap step.
+type StorageKeyProvider struct {
+	// Key is the Caddy storage key the shared identity is stored under.
+	// Defaults to "libp2p/identity.key".
+	Key string `json:"key,omitempty"`
+}
+
+// CaddyModule implements caddy.Module.
+func (StorageKeyProvider) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "libp2p.key.storage",
+		New: func() caddy.Module { return new(StorageKeyProvider) },
+	}
+}
+
+// PrivateKey implements KeyProvider.
+func (s *StorageKeyProvider) PrivateKey(ctx caddy.Context) (crypto.PrivKey, error) {
+	key := s.Key
+	if key == "" {
+		key = "libp2p/identity.key"
+	}
+
+	store := ctx.Storage()
+	if !store.Exists(ctx, key) {
+		return nil, fmt.Errorf("libp2p.key.storage: no identity provisioned under storage key %q", key)
+	}
+
+	raw, err := store.Load(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("loading shared identity: %w", err)
+	}
+	return crypto.UnmarshalPrivateKey(raw)
+}
+
+var (
+	_ caddy.Module = (*StorageKeyProvider)(nil)
+	_ KeyProvider  = (*StorageKeyProvider)(nil)
+)
@@ -0,0 +1,356 @@
+package caddy_libp2p_listener
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	p2pforge "github.com/ipshipyard/p2p-forge/client"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	libp2pquic "github.com/libp2p/go-libp2p/p2p/transport/quic"
+	libp2ptcp "github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	libp2pwebrtc "github.com/libp2p/go-libp2p/p2p/transport/webrtc"
+	libp2pwebtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
+	libp2pws "github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	"github.com/multiformats/go-multiaddr"
+	mafilt "github.com/whyrusleeping/multiaddr-filter"
+)
+
+func init() {
+	caddy.RegisterModule(&ListenerConfig{})
+}
+
+// ListenerConfig is the structured configuration for the libp2p host
+// underlying this module's listener, loaded as a libp2p.listener module. It
+// replaces the hardcoded DefaultTransports + WebRTC options that
+// registerMultiaddrURI previously used for every listen address, letting a
+// Caddy config select transports, NAT/relay behavior, announced addresses,
+// address filters, and resource limits.
+//
+// Deliberate deviation from a "real" caddy.listeners module: a libp2p host
+// isn't a net.Listener wrapped around an existing net.Listener (the shape
+// caddy.ListenerWrapper/NetworkConverter assume), it's a multi-transport
+// swarm that owns its own listen addresses. So ListenerConfig doesn't
+// implement caddy.ListenerWrapper; it's loaded via App.ListenerRaw and
+// consumed by buildListenerOptions to configure the App's shared host
+// instead, with registerMultiaddrURI (module.go) bridging Caddy's "listen"
+// directive to that host via the multiaddr: network.
+type ListenerConfig struct {
+	// ListenAddrs are additional multiaddrs the libp2p host listens on,
+	// e.g. "/ip4/0.0.0.0/udp/4001/quic-v1". The address from the Caddy
+	// "listen" directive (via the multiaddr: network) is always included.
+	ListenAddrs []string `json:"listen,omitempty"`
+
+	// Transports enables specific libp2p transports: "tcp", "quic", "ws",
+	// "wss", "webtransport", and "webrtc-direct". If empty, defaults to
+	// libp2p.DefaultTransports.
+	Transports []string `json:"transports,omitempty"`
+
+	// NAT holds NAT traversal and relay settings.
+	NAT *NATConfig `json:"nat,omitempty"`
+
+	// Announce are additional external multiaddrs to advertise regardless
+	// of what the host observes.
+	Announce []string `json:"announce,omitempty"`
+
+	// NoAnnounce removes matching multiaddrs from the advertised set.
+	NoAnnounce []string `json:"no_announce,omitempty"`
+
+	// AddressFilters denies dialing or accepting connections on matching
+	// addresses, using the whyrusleeping/multiaddr-filter mask syntax (the
+	// same syntax as kubo's Swarm.AddrFilters).
+	AddressFilters []string `json:"address_filters,omitempty"`
+
+	// ConnectionManager bounds the number of live connections.
+	ConnectionManager *ConnManagerConfig `json:"connection_manager,omitempty"`
+
+	// ResourceManager scales go-libp2p's resource manager limits.
+	ResourceManager *ResourceManagerConfig `json:"resource_manager,omitempty"`
+
+	filters *multiaddr.Filters
+}
+
+// NATConfig configures NAT traversal and circuit relay behavior.
+type NATConfig struct {
+	// EnableNATPortMap attempts to open a port in the local NAT via
+	// UPnP/NAT-PMP.
+	EnableNATPortMap bool `json:"enable_nat_port_map,omitempty"`
+
+	// EnableRelayClient allows dialing and being dialed through relays
+	// when direct connectivity isn't available.
+	EnableRelayClient bool `json:"enable_relay_client,omitempty"`
+
+	// EnableRelayService lets this host relay traffic for other peers.
+	EnableRelayService bool `json:"enable_relay_service,omitempty"`
+
+	// EnableHolePunching enables DCUtR hole punching for relayed
+	// connections.
+	EnableHolePunching bool `json:"enable_hole_punching,omitempty"`
+}
+
+// ConnManagerConfig configures the connection manager's watermarks, mirroring
+// go-libp2p's connmgr.NewConnManager.
+type ConnManagerConfig struct {
+	// Low is the number of connections the manager trims down to once High
+	// is exceeded.
+	Low int `json:"low,omitempty"`
+
+	// High is the connection count that triggers pruning.
+	High int `json:"high,omitempty"`
+
+	// Grace is how long a newly opened connection is protected from
+	// pruning, as a Go duration string (e.g. "30s").
+	Grace string `json:"grace,omitempty"`
+}
+
+// ResourceManagerConfig scales go-libp2p's resource manager default limits.
+type ResourceManagerConfig struct {
+	// MemoryFraction is the fraction (0, 1] of system memory the resource
+	// manager is allowed to reserve across all scopes. If zero, the limits
+	// are not scaled by memory: go-libp2p's base default limits are used,
+	// and system memory is not autodetected.
+	MemoryFraction float64 `json:"memory_fraction,omitempty"`
+
+	// MaxFileDescriptors caps the file descriptors the resource manager
+	// will allow. If zero, go-libp2p's own default is used.
+	MaxFileDescriptors int `json:"max_file_descriptors,omitempty"`
+}
+
+// CaddyModule implements caddy.Module.
+func (ListenerConfig) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "libp2p.listener",
+		New: func() caddy.Module { return new(ListenerConfig) },
+	}
+}
+
+// Provision implements caddy.Provisioner.
+func (l *ListenerConfig) Provision(caddy.Context) error {
+	if len(l.AddressFilters) == 0 {
+		return nil
+	}
+	filters := multiaddr.NewFilters()
+	for _, mask := range l.AddressFilters {
+		ipnet, err := mafilt.NewMask(mask)
+		if err != nil {
+			return fmt.Errorf("parsing address filter %q: %w", mask, err)
+		}
+		filters.AddFilter(*ipnet, multiaddr.ActionDeny)
+	}
+	l.filters = filters
+	return nil
+}
+
+// addrsFactory builds a libp2p AddrsFactory from Announce/NoAnnounce, or nil
+// if neither is set.
+func (l *ListenerConfig) addrsFactory() (func([]multiaddr.Multiaddr) []multiaddr.Multiaddr, error) {
+	if len(l.Announce) == 0 && len(l.NoAnnounce) == 0 {
+		return nil, nil
+	}
+
+	announce := make([]multiaddr.Multiaddr, 0, len(l.Announce))
+	for _, a := range l.Announce {
+		ma, err := multiaddr.NewMultiaddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("parsing announce address %q: %w", a, err)
+		}
+		announce = append(announce, ma)
+	}
+
+	noAnnounce := make([]multiaddr.Multiaddr, 0, len(l.NoAnnounce))
+	for _, a := range l.NoAnnounce {
+		ma, err := multiaddr.NewMultiaddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("parsing no_announce address %q: %w", a, err)
+		}
+		noAnnounce = append(noAnnounce, ma)
+	}
+
+	return func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+		out := append(append([]multiaddr.Multiaddr{}, addrs...), announce...)
+		return slices.DeleteFunc(out, func(a multiaddr.Multiaddr) bool {
+			for _, na := range noAnnounce {
+				if na.Equal(a) {
+					return true
+				}
+			}
+			return false
+		})
+	}, nil
+}
+
+func (c *ConnManagerConfig) build() (*connmgr.BasicConnMgr, error) {
+	var opts []connmgr.Option
+	if c.Grace != "" {
+		grace, err := time.ParseDuration(c.Grace)
+		if err != nil {
+			return nil, fmt.Errorf("parsing connection manager grace period: %w", err)
+		}
+		opts = append(opts, connmgr.WithGracePeriod(grace))
+	}
+	return connmgr.NewConnManager(c.Low, c.High, opts...)
+}
+
+func (r *ResourceManagerConfig) build() (network.ResourceManager, error) {
+	limits := rcmgr.DefaultLimits
+	libp2p.SetDefaultServiceLimits(&limits)
+
+	var memBytes int64
+	if r.MemoryFraction > 0 {
+		memBytes = int64(float64(rcmgr.GetMemoryLimit()) * r.MemoryFraction)
+	}
+	limiter := rcmgr.NewFixedLimiter(limits.Scale(memBytes, int64(r.MaxFileDescriptors)))
+	return rcmgr.NewResourceManager(limiter)
+}
+
+// transportOption maps a transport name onto the libp2p.Option that enables
+// it.
+func transportOption(name string) (libp2p.Option, error) {
+	switch name {
+	case "tcp":
+		return libp2p.Transport(libp2ptcp.NewTCPTransport), nil
+	case "quic":
+		return libp2p.Transport(libp2pquic.NewTransport), nil
+	case "ws", "wss":
+		return libp2p.Transport(libp2pws.New), nil
+	case "webtransport":
+		return libp2p.Transport(libp2pwebtransport.New), nil
+	case "webrtc-direct":
+		return libp2p.Transport(libp2pwebrtc.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported transport %q", name)
+	}
+}
+
+// buildListenerOptions assembles the libp2p.Options for the App's shared
+// host, combining the fixed address from the Caddy "listen" directive (if
+// any; ma may be nil when building a dial-only host) with whatever
+// ListenerConfig was attached to the libp2p App. A nil ListenerConfig
+// preserves the prior behavior of this module: DefaultTransports plus
+// WebRTC. If the App has an AutoCert subsystem configured, its certificate
+// manager is wired into the WebTransport transport and folded into the
+// AddrsFactory so advertised certhashes stay current as certificates
+// rotate.
+func buildListenerOptions(app *App, ma multiaddr.Multiaddr) ([]libp2p.Option, error) {
+	lc := app.Listener()
+	certMgr := app.CertManager()
+
+	var opts []libp2p.Option
+	if ma != nil {
+		opts = append(opts, libp2p.ListenAddrs(ma))
+	}
+	if gater := app.ConnectionGater(); gater != nil {
+		opts = append(opts, libp2p.ConnectionGater(gater))
+	}
+
+	if lc == nil {
+		opts = append(opts, libp2p.DefaultTransports)
+		if certMgr != nil {
+			opts = append(opts, libp2p.Transport(libp2pwebtransport.New, libp2pwebtransport.WithCertManager(certMgr)))
+		} else {
+			opts = append(opts, libp2p.Transport(libp2pwebtransport.New))
+		}
+		opts = append(opts, libp2p.Transport(libp2pwebrtc.New))
+		return appendAddrsFactory(opts, nil, certMgr)
+	}
+
+	for _, addr := range lc.ListenAddrs {
+		extra, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing listener address %q: %w", addr, err)
+		}
+		opts = append(opts, libp2p.ListenAddrs(extra))
+	}
+
+	transports := lc.Transports
+	if len(transports) == 0 {
+		// Mirrors the transport set in libp2p.DefaultTransports, so
+		// attaching a ListenerConfig just for nat/connection_manager/etc.
+		// doesn't silently drop ws/webtransport support relative to the
+		// zero-config path a few lines up.
+		transports = []string{"tcp", "quic", "ws", "webtransport", "webrtc-direct"}
+	}
+	for _, t := range transports {
+		if t == "webtransport" && certMgr != nil {
+			opts = append(opts, libp2p.Transport(libp2pwebtransport.New, libp2pwebtransport.WithCertManager(certMgr)))
+			continue
+		}
+		topt, err := transportOption(t)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, topt)
+	}
+
+	if lc.NAT != nil {
+		if lc.NAT.EnableNATPortMap {
+			opts = append(opts, libp2p.NATPortMap())
+		}
+		if lc.NAT.EnableRelayClient {
+			opts = append(opts, libp2p.EnableRelay())
+		}
+		if lc.NAT.EnableRelayService {
+			opts = append(opts, libp2p.EnableRelayService())
+		}
+		if lc.NAT.EnableHolePunching {
+			opts = append(opts, libp2p.EnableHolePunching())
+		}
+	}
+
+	if lc.filters != nil {
+		opts = append(opts, libp2p.Filters(lc.filters))
+	}
+
+	if lc.ConnectionManager != nil {
+		cm, err := lc.ConnectionManager.build()
+		if err != nil {
+			return nil, fmt.Errorf("building connection manager: %w", err)
+		}
+		opts = append(opts, libp2p.ConnectionManager(cm))
+	}
+
+	if lc.ResourceManager != nil {
+		rm, err := lc.ResourceManager.build()
+		if err != nil {
+			return nil, fmt.Errorf("building resource manager: %w", err)
+		}
+		opts = append(opts, libp2p.ResourceManager(rm))
+	}
+
+	af, err := lc.addrsFactory()
+	if err != nil {
+		return nil, err
+	}
+	return appendAddrsFactory(opts, af, certMgr)
+}
+
+// appendAddrsFactory combines the ListenerConfig's announce/no_announce
+// factory (if any) with the AutoCert manager's certhash-rewriting factory
+// (if any), applying the former before the latter, and appends the result
+// as a libp2p.AddrsFactory option.
+func appendAddrsFactory(opts []libp2p.Option, af func([]multiaddr.Multiaddr) []multiaddr.Multiaddr, certMgr *p2pforge.P2PForgeCertMgr) ([]libp2p.Option, error) {
+	factory := af
+	if certMgr != nil {
+		forgeFactory := certMgr.AddrsFactory()
+		inner := factory
+		factory = func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+			if inner != nil {
+				addrs = inner(addrs)
+			}
+			return forgeFactory(addrs)
+		}
+	}
+	if factory != nil {
+		opts = append(opts, libp2p.AddrsFactory(factory))
+	}
+	return opts, nil
+}
+
+var (
+	_ caddy.Module      = (*ListenerConfig)(nil)
+	_ caddy.Provisioner = (*ListenerConfig)(nil)
+)
@@ -0,0 +1,270 @@
+package caddy_libp2p_listener
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	caddy.RegisterModule(new(PubsubHandler))
+	httpcaddyfile.RegisterHandlerDirective("libp2p_pubsub", parsePubsubCaddyfile)
+}
+
+// PubSub lazily builds and returns the gossipsub instance shared by every
+// libp2p_pubsub handler configured against this App, so multiple
+// libp2p_pubsub directives on the same host join topics on one PubSub instance rather than
+// each registering its own stream handlers on the shared host and clobbering
+// one another. opts, maxSize, and hasScore are only applied when this call is
+// the one that constructs the instance, since MaxMessageSize/Score are
+// actually process-wide gossipsub settings despite being exposed per-handler;
+// later callers whose maxSize/hasScore disagree with what's active get a
+// warning logged so the mismatch isn't silently dropped.
+func (t *App) PubSub(ctx caddy.Context, topic string, maxSize int64, hasScore bool, opts ...pubsub.Option) (*pubsub.PubSub, error) {
+	t.psOnce.Do(func() {
+		host, err := t.Host(ctx)
+		if err != nil {
+			t.psErr = err
+			return
+		}
+		t.ps, t.psErr = pubsub.NewGossipSub(ctx, host, opts...)
+		if t.psErr == nil {
+			t.psTopic, t.psMaxSize, t.psHasScore = topic, maxSize, hasScore
+		}
+	})
+	if t.psErr == nil && topic != t.psTopic && (maxSize != t.psMaxSize || hasScore != t.psHasScore) {
+		t.logger.Warn("libp2p_pubsub: max_message_size/score are process-wide gossipsub settings; ignoring this topic's config since they were already set by an earlier libp2p_pubsub directive",
+			zap.String("topic", topic),
+			zap.String("active_config_topic", t.psTopic),
+		)
+	}
+	return t.ps, t.psErr
+}
+
+// PubsubHandler publishes request bodies to a gossipsub topic and, for
+// non-publish requests, streams that topic back out as an SSE event
+// source. It reuses the libp2p App's shared host, so a single libp2p node
+// serves both HTTP and pubsub traffic.
+type PubsubHandler struct {
+	// Topic is the gossipsub topic to publish to and subscribe from.
+	Topic string `json:"topic,omitempty"`
+
+	// MaxMessageSize caps accepted published message bodies, in bytes.
+	// Defaults to 1 MiB.
+	MaxMessageSize int64 `json:"max_message_size,omitempty"`
+
+	// RateLimit caps the number of publishes per second this handler will
+	// accept across all publishers. Zero disables rate limiting.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+
+	// RequireSignature rejects published messages not signed by the
+	// publishing peer's libp2p identity.
+	RequireSignature bool `json:"require_signature,omitempty"`
+
+	// Score configures this topic's peer-scoring parameters.
+	Score *PubsubScoreConfig `json:"score,omitempty"`
+
+	ps      *pubsub.PubSub
+	topic   *pubsub.Topic
+	limiter *rate.Limiter
+}
+
+// PubsubScoreConfig configures gossipsub's per-topic peer-scoring
+// parameters, analogous to BlossomSub's BitmaskScoreParams.
+type PubsubScoreConfig struct {
+	// GreyThreshold is the score below which a peer's messages are ignored
+	// but the peer isn't yet disconnected.
+	GreyThreshold float64 `json:"grey_threshold,omitempty"`
+
+	// PositiveThreshold is the score above which a peer is treated as a
+	// first-class mesh participant for this topic.
+	PositiveThreshold float64 `json:"positive_threshold,omitempty"`
+
+	// DecayInterval is how often this topic's score counters decay, as a Go
+	// duration string. Defaults to one second.
+	DecayInterval string `json:"decay_interval,omitempty"`
+
+	// Weight scales this topic's contribution to a peer's overall score.
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// buildOption turns a PubsubScoreConfig into a pubsub.WithPeerScore option
+// scoped to the given topic.
+func (s *PubsubScoreConfig) buildOption(topic string) (pubsub.Option, error) {
+	decay := time.Second
+	if s.DecayInterval != "" {
+		d, err := time.ParseDuration(s.DecayInterval)
+		if err != nil {
+			return nil, fmt.Errorf("parsing decay_interval: %w", err)
+		}
+		decay = d
+	}
+
+	params := &pubsub.PeerScoreParams{
+		Topics: map[string]*pubsub.TopicScoreParams{
+			topic: {TopicWeight: s.Weight},
+		},
+		DecayInterval: decay,
+		DecayToZero:   0.01,
+	}
+	thresholds := &pubsub.PeerScoreThresholds{
+		GossipThreshold:  s.GreyThreshold,
+		PublishThreshold: s.PositiveThreshold,
+	}
+	return pubsub.WithPeerScore(params, thresholds), nil
+}
+
+// CaddyModule implements caddy.Module.
+func (PubsubHandler) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.libp2p_pubsub",
+		New: func() caddy.Module { return new(PubsubHandler) },
+	}
+}
+
+// Provision implements caddy.Provisioner.
+func (p *PubsubHandler) Provision(ctx caddy.Context) error {
+	if p.Topic == "" {
+		return fmt.Errorf("libp2p_pubsub: topic is required")
+	}
+	if p.MaxMessageSize == 0 {
+		p.MaxMessageSize = 1 << 20
+	}
+
+	appIface, err := ctx.App("libp2p")
+	if err != nil {
+		return err
+	}
+	app, ok := appIface.(*App)
+	if !ok {
+		return fmt.Errorf("libp2p app is not *App: %T", appIface)
+	}
+
+	psOpts := []pubsub.Option{pubsub.WithMaxMessageSize(int(p.MaxMessageSize))}
+	if p.Score != nil {
+		scoreOpt, err := p.Score.buildOption(p.Topic)
+		if err != nil {
+			return fmt.Errorf("building score params for topic %q: %w", p.Topic, err)
+		}
+		psOpts = append(psOpts, scoreOpt)
+	}
+
+	ps, err := app.PubSub(ctx, p.Topic, p.MaxMessageSize, p.Score != nil, psOpts...)
+	if err != nil {
+		return fmt.Errorf("getting libp2p pubsub: %w", err)
+	}
+	p.ps = ps
+
+	topic, err := ps.Join(p.Topic)
+	if err != nil {
+		return fmt.Errorf("joining topic %q: %w", p.Topic, err)
+	}
+	p.topic = topic
+
+	if p.RequireSignature {
+		if err := ps.RegisterTopicValidator(p.Topic, func(_ context.Context, _ peer.ID, msg *pubsub.Message) bool {
+			return msg.Signature != nil
+		}); err != nil {
+			return fmt.Errorf("registering signature validator for topic %q: %w", p.Topic, err)
+		}
+	}
+
+	if p.RateLimit > 0 {
+		p.limiter = rate.NewLimiter(rate.Limit(p.RateLimit), 1)
+	}
+
+	return nil
+}
+
+// ServeHTTP implements caddyhttp.MiddlewareHandler. POST requests publish
+// their body to Topic; any other method subscribes to Topic and streams
+// messages back as an SSE event source.
+func (p *PubsubHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if r.Method == http.MethodPost {
+		return p.publish(w, r)
+	}
+	return p.subscribe(w, r)
+}
+
+func (p *PubsubHandler) publish(w http.ResponseWriter, r *http.Request) error {
+	if p.limiter != nil && !p.limiter.Allow() {
+		w.WriteHeader(http.StatusTooManyRequests)
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, p.MaxMessageSize+1))
+	if err != nil {
+		return fmt.Errorf("reading publish body: %w", err)
+	}
+	if int64(len(body)) > p.MaxMessageSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return nil
+	}
+
+	if err := p.topic.Publish(r.Context(), body); err != nil {
+		return fmt.Errorf("publishing to topic %q: %w", p.Topic, err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+func (p *PubsubHandler) subscribe(w http.ResponseWriter, r *http.Request) error {
+	sub, err := p.topic.Subscribe()
+	if err != nil {
+		return fmt.Errorf("subscribing to topic %q: %w", p.Topic, err)
+	}
+	defer sub.Cancel()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming requires a flushable ResponseWriter")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	ctx := r.Context()
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return nil
+		}
+		if _, err := fmt.Fprintf(bw, "data: %s\n\n", msg.Data); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		flusher.Flush()
+	}
+}
+
+// parsePubsubCaddyfile unmarshals a caddyfile helper into a PubsubHandler.
+func parsePubsubCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	p := new(PubsubHandler)
+	h.Next()
+	if !h.NextArg() {
+		return nil, h.ArgErr()
+	}
+	p.Topic = h.Val()
+	return p, nil
+}
+
+var (
+	_ caddy.Module                = (*PubsubHandler)(nil)
+	_ caddy.Provisioner           = (*PubsubHandler)(nil)
+	_ caddyhttp.MiddlewareHandler = (*PubsubHandler)(nil)
+)
@@ -0,0 +1,89 @@
+package caddy_libp2p_listener
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	libp2phttp "github.com/libp2p/go-libp2p/p2p/http"
+)
+
+func init() {
+	caddy.RegisterModule(new(Libp2pTransport))
+}
+
+// Libp2pTransport is a reverse_proxy transport that dials upstreams over
+// libp2p instead of plain TCP/TLS, reusing the libp2p App's shared host. It
+// is the outbound counterpart of Libp2pHandler: where that module serves
+// the .well-known/libp2p/protocols document, this one consumes it on the
+// upstream side to resolve the right path prefix for a request.
+//
+// Upstreams are addressed as multiaddr://<peer-id>/..., where <peer-id> is
+// resolved to an address via the libp2p peerstore/DHT the same way any
+// libp2p dial is. If Protocol is set, requests are additionally routed
+// through libp2phttp's namespaced client for that protocol ID (e.g.
+// "/ipni/v1/", "/ipfs/gateway/1.0.0"), which rewrites the request path
+// using the upstream's advertised protocol metadata.
+type Libp2pTransport struct {
+	// Protocol pins requests through this transport to a specific libp2p
+	// protocol ID, resolved via the upstream's well-known protocol document
+	// rather than a literal HTTP path.
+	Protocol string `json:"protocol,omitempty"`
+
+	h *libp2phttp.Host
+}
+
+// CaddyModule implements caddy.Module.
+func (Libp2pTransport) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.reverse_proxy.transport.libp2p",
+		New: func() caddy.Module { return new(Libp2pTransport) },
+	}
+}
+
+// Provision implements caddy.Provisioner.
+func (lt *Libp2pTransport) Provision(ctx caddy.Context) error {
+	appIface, err := ctx.App("libp2p")
+	if err != nil {
+		return err
+	}
+	app, ok := appIface.(*App)
+	if !ok {
+		return fmt.Errorf("libp2p app is not *App: %T", appIface)
+	}
+
+	host, err := app.Host(ctx)
+	if err != nil {
+		return fmt.Errorf("getting libp2p host: %w", err)
+	}
+	lt.h = &libp2phttp.Host{StreamHost: host}
+
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper, dialing the request's upstream
+// over libp2p.
+func (lt *Libp2pTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if lt.Protocol == "" {
+		return lt.h.RoundTrip(req)
+	}
+
+	id, err := peer.Decode(req.URL.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("parsing peer ID from upstream %q: %w", req.URL.Hostname(), err)
+	}
+
+	client, err := lt.h.NamespacedClient(protocol.ID(lt.Protocol), peer.AddrInfo{ID: id})
+	if err != nil {
+		return nil, fmt.Errorf("resolving protocol %q for peer %s: %w", lt.Protocol, id, err)
+	}
+	return client.Do(req)
+}
+
+var (
+	_ caddy.Module      = (*Libp2pTransport)(nil)
+	_ caddy.Provisioner = (*Libp2pTransport)(nil)
+	_ http.RoundTripper = (*Libp2pTransport)(nil)
+)